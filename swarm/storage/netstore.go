@@ -20,14 +20,51 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"math"
 	"math/rand"
 	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/swarm/log"
+)
+
+const (
+	// defaultMaxHopCount is the default number of times a chunk request may be
+	// forwarded from peer to peer before the fetcher gives up forwarding it and
+	// just lets the request context expire instead of blindly re-requesting.
+	defaultMaxHopCount uint8 = 20
+
+	// defaultIdleFetcherTTL is the default duration a fetcher may go without a single
+	// Fetch/FetchAsync call before the idle-fetcher reaper force-removes it from the fetchers map.
+	defaultIdleFetcherTTL = 5 * time.Minute
+
+	// defaultFetcherStrategy is the name NetStore registers its constructor NewNetFetcherFunc
+	// under, and the strategy used when a request does not ask for anything else.
+	defaultFetcherStrategy = "default"
+
+	// hopCountCoalesceWindow is how long the goroutine that won the right to send a fetcher's
+	// outgoing request waits before reading the shared minimum hop count and actually sending,
+	// so that other Fetch/FetchAsync calls arriving at nearly the same time have a chance to
+	// merge in a lower hop count first. Without this window, whichever goroutine wins the race
+	// sends immediately with whatever hop count it alone observed.
+	hopCountCoalesceWindow = 5 * time.Millisecond
+)
 
-	lru "github.com/hashicorp/golang-lru"
+var (
+	netstoreChunkPutCount         = metrics.NewRegisteredCounter("netstore.chunk.put", nil)
+	netstoreChunkGetCacheHit      = metrics.NewRegisteredCounter("netstore.chunk.get.hit", nil)
+	netstoreChunkGetCacheMiss     = metrics.NewRegisteredCounter("netstore.chunk.get.miss", nil)
+	netstoreFetcherCreateCount    = metrics.NewRegisteredCounter("netstore.fetcher.create", nil)
+	netstoreFetcherDestroyCount   = metrics.NewRegisteredCounter("netstore.fetcher.destroy", nil)
+	netstoreFetcherCoalescedCount = metrics.NewRegisteredCounter("netstore.fetcher.coalesced", nil)
+	netstoreFetcherRequestCount   = metrics.NewRegisteredCounter("netstore.fetcher.request", nil)
+	netstoreFetcherOfferCount     = metrics.NewRegisteredCounter("netstore.fetcher.offer", nil)
+	netstoreFetcherLatencyTimer   = metrics.NewRegisteredTimer("netstore.fetcher.latency", nil)
+	netstoreFetcherHopCountHist   = metrics.NewRegisteredHistogram("netstore.fetcher.hopcount", nil, metrics.NewExpDecaySample(1028, 0.015))
+	netstoreFetcherRequestsHist   = metrics.NewRegisteredHistogram("netstore.fetcher.requests", nil, metrics.NewExpDecaySample(1028, 0.015))
 )
 
 type (
@@ -35,47 +72,140 @@ type (
 )
 
 type NetFetcher interface {
-	Request(ctx context.Context)
+	Request(ctx context.Context, hopCount uint8)
 	Offer(ctx context.Context, source *discover.NodeID)
 }
 
+// requestOriginKey is the unexported context key RequestOrigin values are stored under, so that
+// only WithRequestOrigin/RequestOriginFromContext in this package can set or read it.
+type requestOriginKey struct{}
+
+// RequestOrigin describes why NetStore is being asked for a chunk: Peer is the node that is
+// asking us (if any, so we don't request back from it), Source is the node that offered the
+// chunk directly (making this an offer rather than a request), and HopCount is the number of
+// times the request has already been forwarded from peer to peer. It replaces the former
+// stringly-typed rctx.Value("peer") / rctx.Value("source") dispatch
+// with a typed, exported boundary between the storage and network packages.
+type RequestOrigin struct {
+	Peer     *discover.NodeID
+	Source   *discover.NodeID
+	HopCount uint8
+}
+
+// WithRequestOrigin returns a copy of ctx carrying origin, retrievable with RequestOriginFromContext.
+func WithRequestOrigin(ctx context.Context, origin RequestOrigin) context.Context {
+	return context.WithValue(ctx, requestOriginKey{}, origin)
+}
+
+// RequestOriginFromContext extracts the RequestOrigin previously attached with WithRequestOrigin.
+// ok is false if ctx carries none, in which case origin is the zero value (a plain, local request).
+func RequestOriginFromContext(ctx context.Context) (origin RequestOrigin, ok bool) {
+	origin, ok = ctx.Value(requestOriginKey{}).(RequestOrigin)
+	return origin, ok
+}
+
 type WithNetStoreId interface {
 	SetNetstoreId(id int)
 }
 
+// fetcherStrategyKey is the unexported context key a fetcher strategy name is stored under.
+type fetcherStrategyKey struct{}
+
+// WithFetcherStrategy returns a copy of ctx requesting that the chunk be retrieved through the
+// NetFetcher strategy registered under name (see NetStore.RegisterFetcherStrategy), instead of
+// the default kademlia-forwarding one.
+func WithFetcherStrategy(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, fetcherStrategyKey{}, name)
+}
+
+// FetcherStrategyFromContext extracts the strategy name previously attached with
+// WithFetcherStrategy. ok is false if ctx carries none.
+func FetcherStrategyFromContext(ctx context.Context) (name string, ok bool) {
+	name, ok = ctx.Value(fetcherStrategyKey{}).(string)
+	return name, ok
+}
+
+// StrategySelector picks the name of the registered NetFetcher strategy that should retrieve
+// addr, given the requesting context. The default selector honours WithFetcherStrategy and
+// otherwise falls back to defaultFetcherStrategy; NetStore.StrategySelector can be replaced to
+// choose a strategy from the chunk address itself instead (e.g. a reserved address prefix for
+// pinned/pre-advertised content).
+type StrategySelector func(ctx context.Context, addr Address) string
+
+func defaultStrategySelector(ctx context.Context, addr Address) string {
+	if name, ok := FetcherStrategyFromContext(ctx); ok {
+		return name
+	}
+	return defaultFetcherStrategy
+}
+
 // NetStore is an extension of local storage
 // it implements the ChunkStore interface
 // on request it initiates remote cloud retrieval using a fetcher
-// fetchers are unique to a chunk and are stored in fetchers LRU memory cache
+// fetchers are unique to a chunk and are stored in the fetchers map for the lifetime of the
+// request(s) that need them; they are reference-counted and removed as soon as they are no
+// longer needed, see getOrCreateFetcher and reapIdleFetchers.
 // fetchFuncFactory is a factory object to create a fetch function for a specific chunk address
 type NetStore struct {
 	mu                sync.Mutex
 	store             ChunkStore
-	fetchers          *lru.Cache
+	fetchers          *sync.Map // addr (hex string) -> *fetcher
 	NewNetFetcherFunc NewNetFetcherFunc
-	id                int
+	// MaxHopCount is the maximum number of times a chunk request is allowed to
+	// be forwarded from peer to peer before a fetcher stops forwarding it and
+	// simply waits for its context to expire.
+	MaxHopCount uint8
+	// IdleFetcherTTL bounds how long a fetcher with no pending request may go without a single
+	// Fetch/FetchAsync call before the idle-fetcher reaper force-removes it from the fetchers
+	// map, as a backstop against a fetcher that no request ever drives through the normal
+	// destroy path. Fetchers with a pending request are never reaped.
+	// A value <= 0 disables the reaper.
+	IdleFetcherTTL time.Duration
+	// StrategySelector picks, per request, which of the registered fetcher strategies (see
+	// RegisterFetcherStrategy) should retrieve a given chunk address.
+	StrategySelector StrategySelector
+	strategies       map[string]NewNetFetcherFunc
+	closeC           chan struct{}
+	id               int
 }
 
 // NewNetStore creates a new NetStore object using the given local store. newFetchFunc is a
-// constructor function that can create a fetch function for a specific chunk address.
+// constructor function that can create a fetch function for a specific chunk address. It is
+// registered as the "default" fetcher strategy; additional strategies can be added with
+// RegisterFetcherStrategy.
 func NewNetStore(store ChunkStore, nnf NewNetFetcherFunc) (*NetStore, error) {
-	fetchers, err := lru.New(defaultChunkRequestsCacheCapacity)
-	if err != nil {
-		return nil, err
-	}
-
-	return &NetStore{
+	n := &NetStore{
 		store:             store,
-		fetchers:          fetchers,
+		fetchers:          &sync.Map{},
 		NewNetFetcherFunc: nnf,
+		MaxHopCount:       defaultMaxHopCount,
+		IdleFetcherTTL:    defaultIdleFetcherTTL,
+		StrategySelector:  defaultStrategySelector,
+		strategies:        map[string]NewNetFetcherFunc{defaultFetcherStrategy: nnf},
+		closeC:            make(chan struct{}),
 		id:                rand.Intn(10000),
-	}, nil
+	}
+	go n.reapIdleFetchers()
+	return n, nil
+}
+
+// RegisterFetcherStrategy registers f as an alternative NetFetcher constructor under name, so
+// that a request can be retrieved through it instead of the default kademlia-forwarding
+// strategy, either by attaching WithFetcherStrategy(ctx, name) to the request context or by
+// customizing StrategySelector to pick it from the chunk address. This is a precondition for
+// introducing retrieval protocols other than the default one (e.g. a direct-trust fetcher for
+// pinned content, or a bulk-syncer fetcher for range-based catch-up) without forking NetStore.
+func (n *NetStore) RegisterFetcherStrategy(name string, f NewNetFetcherFunc) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.strategies[name] = f
 }
 
 // Put stores a chunk in localstore, and delivers to all requestor peers using the fetcher stored in
 // the fetchers cache
 func (n *NetStore) Put(ctx context.Context, ch Chunk) error {
-	log.Warn("Chunk is put", "addr", ch.Address(), "netstore", n.id)
+	log.Trace("Chunk is put", "addr", ch.Address(), "netstore", n.id)
+	netstoreChunkPutCount.Inc(1)
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
@@ -103,61 +233,149 @@ func (n *NetStore) Put(ctx context.Context, ch Chunk) error {
 // it calls fetch with the request, which blocks until the chunk
 // arrived or context is done
 func (n *NetStore) Get(rctx context.Context, ref Address) (Chunk, error) {
-	chunk, fetch, err := n.get(rctx, ref)
-	if fetch == nil {
+	chunk, f, err := n.get(rctx, ref)
+	if f == nil {
 		return chunk, err
 	}
-	return fetch(rctx)
+	return f.Fetch(rctx)
 }
 
 // Has returns nil if the store contains the given address. Otherwise it returns a wait function,
 // which returns after the chunk is available or the context is done
 func (n *NetStore) Has(ctx context.Context, ref Address) func(context.Context) error {
-	chunk, fetch, _ := n.get(ctx, ref)
+	chunk, f, _ := n.get(ctx, ref)
 	if chunk != nil {
 		return nil
 	}
 	return func(ctx context.Context) error {
-		_, err := fetch(ctx)
+		_, err := f.Fetch(ctx)
 		return err
 	}
 }
 
+// ChunkResult pairs a chunk with a possible error. It is the element type of the channels
+// returned by GetAsync, so that a successfully delivered chunk and a failed fetch (e.g. context
+// expired) can be multiplexed onto the same channel.
+type ChunkResult struct {
+	Chunk Chunk
+	Err   error
+}
+
+// GetAsync retrieves the chunk asynchronously. It returns a channel which receives exactly one
+// ChunkResult once the chunk is found in LocalStore, delivered over the network, or the context
+// is done. Unlike Get, it never blocks the calling goroutine, so components issuing many
+// concurrent prefetches (the chunker, the stream delivery loop, the manifest walker) do not need
+// to spawn a goroutine per request.
+func (n *NetStore) GetAsync(ctx context.Context, ref Address) <-chan ChunkResult {
+	chunk, f, err := n.get(ctx, ref)
+	if f == nil {
+		resultC := make(chan ChunkResult, 1)
+		resultC <- ChunkResult{Chunk: chunk, Err: err}
+		close(resultC)
+		return resultC
+	}
+	return f.FetchAsync(ctx)
+}
+
+// HasAsync returns a channel which receives nil once the store contains the given address, or an
+// error if the context is done before the chunk could be retrieved. It is the asynchronous
+// counterpart of Has.
+func (n *NetStore) HasAsync(ctx context.Context, ref Address) <-chan error {
+	chunk, f, err := n.get(ctx, ref)
+	if f == nil {
+		errC := make(chan error, 1)
+		if chunk != nil {
+			err = nil
+		}
+		errC <- err
+		close(errC)
+		return errC
+	}
+	errC := make(chan error, 1)
+	go func() {
+		result := <-f.FetchAsync(ctx)
+		errC <- result.Err
+		close(errC)
+	}()
+	return errC
+}
+
 // Close chunk store
 func (n *NetStore) Close() {
+	close(n.closeC)
 	n.store.Close()
 }
 
+// reapIdleFetchers is a backstop against fetchers that were never removed through the normal
+// destroy-on-zero-refcount path because no Fetch/FetchAsync call ever settled on them (e.g. a
+// fetcher created by Has whose wait function is never called): it periodically scans the fetchers
+// map and force-removes
+// any fetcher that both has requestCnt == 0 (so it genuinely has no live Fetch/FetchAsync waiter
+// that could still be delivered to) and has not seen a Fetch/FetchAsync call for longer than
+// IdleFetcherTTL, so a stray entry cannot pin memory (and its NetFetcher's context) forever.
+// A fetcher whose requestCnt stays above zero is therefore never removed by the reaper.
+// A fetcher with a live, merely slow, in-flight caller is never touched regardless of how idle it
+// looks, since force-cancelling it would strand that caller without ever being delivered to by a
+// subsequent Put. A zero or negative IdleFetcherTTL disables the reaper, since time.NewTicker
+// rejects non-positive durations.
+func (n *NetStore) reapIdleFetchers() {
+	if n.IdleFetcherTTL <= 0 {
+		return
+	}
+	ticker := time.NewTicker(n.IdleFetcherTTL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-n.closeC:
+			return
+		case now := <-ticker.C:
+			n.fetchers.Range(func(key, value interface{}) bool {
+				f := value.(*fetcher)
+				if atomic.LoadInt32(&f.requestCnt) != 0 {
+					// has a live waiter, leave it alone no matter how idle it looks
+					return true
+				}
+				lastActive := atomic.LoadInt64(&f.lastActive)
+				if now.Sub(time.Unix(0, lastActive)) > n.IdleFetcherTTL {
+					f.cancel()
+				}
+				return true
+			})
+		}
+	}
+}
+
 // get attempts at retrieving the chunk from LocalStore
 // If it is not found then using getOrCreateFetcher:
 //     1. Either there is already a fetcher to retrieve it
 //     2. A new fetcher is created and saved in the fetchers cache
 // From here on, all Get will hit on this fetcher until the chunk is delivered
 // or all fetcher contexts are done.
-// It returns a chunk, a fetcher function and an error
-// If chunk is nil, the returned fetch function needs to be called with a context to return the chunk.
-func (n *NetStore) get(ctx context.Context, ref Address) (Chunk, func(context.Context) (Chunk, error), error) {
-	log.Warn("Chunk is get", "addr", ref, "netstore", n.id)
+// It returns a chunk, the fetcher responsible for retrieving it, and an error.
+// If chunk is nil, the returned fetcher needs to be used (via Fetch or FetchAsync) to get the chunk.
+func (n *NetStore) get(ctx context.Context, ref Address) (Chunk, *fetcher, error) {
+	log.Trace("Chunk is get", "addr", ref, "netstore", n.id)
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
 	chunk, err := n.store.Get(ctx, ref)
 	if err == nil {
-		// The chunk is available in the LocalStore, so the returned fetch function is not necessary.
-		// However, we still return a fetch function which immediately returns the same chunk again if called.
-		return chunk, func(context.Context) (Chunk, error) { return chunk, nil }, nil
+		// The chunk is available in the LocalStore, so there is no need for a fetcher.
+		netstoreChunkGetCacheHit.Inc(1)
+		return chunk, nil, nil
 	}
+	netstoreChunkGetCacheMiss.Inc(1)
 	// The chunk is not available in the LocalStore, let's get the fetcher for it, or create a new one
 	// if it doesn't exist yet
-	f := n.getOrCreateFetcher(ref)
-	// If the caller needs the chunk, it has to use the returned fetch function to get it
-	return nil, f.Fetch, err
+	f := n.getOrCreateFetcher(ctx, ref)
+	// If the caller needs the chunk, it has to use the returned fetcher to get it
+	return nil, f, err
 }
 
 // getOrCreateFetcher attempts at retrieving an existing fetchers
 // if none exists, creates one and saves it in the fetchers cache
 // caller must hold the lock
-func (n *NetStore) getOrCreateFetcher(ref Address) *fetcher {
+func (n *NetStore) getOrCreateFetcher(rctx context.Context, ref Address) *fetcher {
 	if f := n.getFetcher(ref); f != nil {
 		return f
 	}
@@ -166,39 +384,61 @@ func (n *NetStore) getOrCreateFetcher(ref Address) *fetcher {
 	key := hex.EncodeToString(ref)
 	// create the context during which fetching is kept alive
 	ctx, cancel := context.WithCancel(context.Background())
-	// destroy is called when all requests finish
+	var f *fetcher
+	// destroy is called when all requests finish, or by reapIdleFetchers if the fetcher has gone
+	// idle; destroyOnce guards against both paths racing to fire for the same fetcher.
+	var destroyOnce sync.Once
 	destroy := func() {
-		// remove fetcher from fetchers
-		n.fetchers.Remove(key)
-		// stop fetcher by cancelling context called when
-		// all requests cancelled/timedout or chunk is delivered
-		cancel()
+		destroyOnce.Do(func() {
+			// remove fetcher from fetchers
+			n.fetchers.Delete(key)
+			// stop fetcher by cancelling context called when
+			// all requests cancelled/timedout or chunk is delivered
+			cancel()
+			netstoreFetcherDestroyCount.Inc(1)
+			netstoreFetcherRequestsHist.Update(int64(atomic.LoadInt32(&f.requests)))
+		})
 	}
 	// peers always stores all the peers which have an active request for the chunk. It is shared
 	// between fetcher and the NewFetchFunc function. It is needed by the NewFetchFunc because
 	// the peers which requested the chunk should not be requested to deliver it.
 	peers := &sync.Map{}
 
-	fetcher := newFetcher(ref, n.NewNetFetcherFunc(ctx, ref, peers), destroy, peers, n.id)
-	n.fetchers.Add(key, fetcher)
+	strategy := n.StrategySelector(rctx, ref)
+	nnf, ok := n.strategies[strategy]
+	if !ok {
+		log.Warn("Fetcher strategy not registered, falling back to default", "strategy", strategy, "addr", ref)
+		strategy = defaultFetcherStrategy
+		nnf = n.NewNetFetcherFunc
+	}
+	metrics.GetOrRegisterCounter(fmt.Sprintf("netstore.fetcher.strategy.%s", strategy), nil).Inc(1)
+
+	f = newFetcher(ctx, ref, nnf(ctx, ref, peers), destroy, peers, n.id, n.MaxHopCount, strategy)
+	n.fetchers.Store(key, f)
+	netstoreFetcherCreateCount.Inc(1)
 
-	return fetcher
+	return f
 }
 
-// getFetcher retrieves the fetcher for the given address from the fetchers cache if it exists,
+// getFetcher retrieves the fetcher for the given address from the fetchers map if it exists,
 // otherwise it returns nil
 func (n *NetStore) getFetcher(ref Address) *fetcher {
 	key := hex.EncodeToString(ref)
-	f, ok := n.fetchers.Get(key)
+	f, ok := n.fetchers.Load(key)
 	if ok {
 		return f.(*fetcher)
 	}
 	return nil
 }
 
-// RequestsCacheLen returns the current number of outgoing requests stored in the cache
+// RequestsCacheLen returns the current number of outgoing requests stored in the fetchers map
 func (n *NetStore) RequestsCacheLen() int {
-	return n.fetchers.Len()
+	count := 0
+	n.fetchers.Range(func(_, _ interface{}) bool {
+		count++
+		return true
+	})
+	return count
 }
 
 // One fetcher object is responsible to fetch one chunk for one address, and keep track of all the
@@ -215,6 +455,13 @@ type fetcher struct {
 	deliverOnce *sync.Once
 	id          int
 	netstoreId  int
+
+	requestSent int32      // 0: no request claimed yet, 1: a goroutine has claimed the outgoing request (it is waiting out hopCountCoalesceWindow or has sent it) and further Fetch calls are coalesced onto it; CAS-guarded, accessed atomically
+	hopCount    uint32     // lowest hop count seen across the coalesced requests so far, accessed atomically
+	maxHopCount uint8      // requests are not forwarded once this many hops have been made
+	requests    int32      // total number of Fetch/FetchAsync calls seen, for the per-chunk request-count metric, accessed atomically
+	lastActive  int64      // unix nano time of the most recent Fetch/FetchAsync call, accessed atomically, used by NetStore.reapIdleFetchers
+	strategy    string     // name of the NetFetcher strategy used to retrieve this chunk, see NetStore.RegisterFetcherStrategy
 }
 
 // newFetcher creates a new fetcher object for the fiven addr. fetch is the function which actually
@@ -223,66 +470,153 @@ type fetcher struct {
 //     1. when the chunk has been fetched all peers have been either notified or their context has been done
 //     2. the chunk has not been fetched but all context from all the requests has been done
 // The peers map stores all the peers which have requested chunk.
-func newFetcher(addr Address, nf NetFetcher, cancel func(), peers *sync.Map, netstoreId int) *fetcher {
+func newFetcher(ctx context.Context, addr Address, nf NetFetcher, cancel func(), peers *sync.Map, netstoreId int, maxHopCount uint8, strategy string) *fetcher {
 	// cancelOnce := &sync.Once{}        // cancel should only be called once
 	// cancelledC := make(chan struct{}) // closed when fetcher is cancelled
-	log.Warn("Fetcher is created for chunk", "addr", addr)
+	log.Trace("Fetcher is created for chunk", "addr", addr, "strategy", strategy)
 	nf.(WithNetStoreId).SetNetstoreId(netstoreId)
-	return &fetcher{
+	f := &fetcher{
 		addr:        addr,
 		deliveredC:  make(chan struct{}),
 		deliverOnce: &sync.Once{},
 		// cancelledC:  cancelledC,
-		netFetcher: nf,
-		cancel:     cancel,
-		peers:      peers,
-		id:         rand.Intn(10000),
-		netstoreId: netstoreId,
+		netFetcher:  nf,
+		cancel:      cancel,
+		peers:       peers,
+		id:          rand.Intn(10000),
+		netstoreId:  netstoreId,
+		hopCount:    uint32(math.MaxUint8),
+		maxHopCount: maxHopCount,
+		lastActive:  time.Now().UnixNano(),
+		strategy:    strategy,
+	}
+	return f
+}
+
+// mergeHopCount records hopCount as the lowest hop count seen so far for this
+// fetcher and returns the resulting minimum. Several Fetch calls arriving in
+// quick succession are coalesced into a single outgoing network request, which
+// carries the smallest hop count merged in before it is sent (within
+// hopCountCoalesceWindow of the first call). A lower hop count merged in after
+// the request has gone out is recorded here but not sent.
+func (f *fetcher) mergeHopCount(hopCount uint8) uint8 {
+	for {
+		cur := atomic.LoadUint32(&f.hopCount)
+		if uint32(hopCount) >= cur {
+			return uint8(cur)
+		}
+		if atomic.CompareAndSwapUint32(&f.hopCount, cur, uint32(hopCount)) {
+			return hopCount
+		}
 	}
 }
 
 // Fetch fetches the chunk synchronously, it is called by NetStore.Get is the chunk is not available
-// locally.
+// locally. It blocks the caller until the chunk is delivered or rctx is done.
 func (f *fetcher) Fetch(rctx context.Context) (Chunk, error) {
+	result := <-f.FetchAsync(rctx)
+	return result.Chunk, result.Err
+}
+
+// FetchAsync is the non-blocking counterpart of Fetch. It issues the same offer/request and
+// multiplexes f.deliveredC and rctx.Done() onto the returned channel instead of blocking the
+// caller, so cancellation via rctx still decrements requestCnt and triggers cancel() exactly
+// like the synchronous path.
+func (f *fetcher) FetchAsync(rctx context.Context) <-chan ChunkResult {
+	resultC := make(chan ChunkResult, 1)
+	start := time.Now()
+
+	atomic.StoreInt64(&f.lastActive, time.Now().UnixNano())
 	atomic.AddInt32(&f.requestCnt, 1)
-	defer func() {
-		// if all the requests are done the fetcher can be cancelled
-		if atomic.AddInt32(&f.requestCnt, -1) == 0 {
-			f.cancel()
-		}
-	}()
+	atomic.AddInt32(&f.requests, 1)
+
+	// origin describes why we are being asked for this chunk; a plain local request (no peer, no
+	// source, hop count 0) if the caller attached none.
+	origin, _ := RequestOriginFromContext(rctx)
 
 	// The peer asking for the chunk. Store in the shared peers map, but delete after the request
 	// has been delivered
-	peer := rctx.Value("peer")
-	if peer != nil {
-		f.peers.Store(peer, true)
-		defer f.peers.Delete(peer)
+	if origin.Peer != nil {
+		f.peers.Store(*origin.Peer, true)
 	}
 
 	// If there is a source in the context then it is an offer, otherwise a request
-	sourceIF := rctx.Value("source")
-	if sourceIF != nil {
-		var source *discover.NodeID
-		id := discover.MustHexID(sourceIF.(string))
-		source = &id
-		log.Warn("Fetcher is doing an offer", "addr", f.addr, "fetcher", f.id, "netstore", f.netstoreId)
-		f.netFetcher.Offer(rctx, source)
+	if origin.Source != nil {
+		log.Trace("Fetcher is doing an offer", "addr", f.addr, "fetcher", f.id, "netstore", f.netstoreId)
+		netstoreFetcherOfferCount.Inc(1)
+		f.netFetcher.Offer(rctx, origin.Source)
 	} else {
-		log.Warn("Fetcher is doing a request", "addr", f.addr, "fetcher", f.id, "netstore", f.netstoreId)
-		f.netFetcher.Request(rctx)
+		// HopCount is the number of times this chunk request has already been
+		// forwarded from peer to peer; it defaults to 0 for a request originated
+		// locally and is incremented every time a fetcher forwards it further.
+		// Saturate instead of wrapping around on uint8 overflow.
+		nextHopCount := origin.HopCount
+		if nextHopCount < math.MaxUint8 {
+			nextHopCount++
+		}
+		f.mergeHopCount(nextHopCount)
+		// Only one goroutine may claim the right to actually send the outgoing request; later
+		// concurrent Fetch calls are coalesced onto it. Unlike a sync.Once, the claim is released
+		// if it turns out not to have sent anything (hop count over budget), so a later call with
+		// a valid, lower hop count merged in by mergeHopCount can still try again.
+		if atomic.CompareAndSwapInt32(&f.requestSent, 0, 1) {
+			// Send from a separate goroutine, after a brief coalescing window, so that
+			// concurrently-arriving Fetch calls (this is FetchAsync, so they don't block on
+			// each other) have a chance to merge in a lower hop count before the minimum is
+			// actually read and sent, instead of the winner firing immediately with whatever
+			// it alone computed. FetchAsync itself must stay non-blocking, so this cannot
+			// happen inline.
+			go func() {
+				time.Sleep(hopCountCoalesceWindow)
+				// Re-read the shared minimum at send time rather than using the value this
+				// goroutine computed above, so a lower hop count merged in by a concurrent
+				// Fetch call in the meantime is not lost.
+				hopCount := uint8(atomic.LoadUint32(&f.hopCount))
+				if hopCount > f.maxHopCount {
+					log.Trace("Fetcher reached max hop count, not forwarding request", "addr", f.addr, "fetcher", f.id, "netstore", f.netstoreId, "hopCount", hopCount, "maxHopCount", f.maxHopCount)
+					// Nothing was actually sent, release the claim so a future call with a
+					// lower hop count is not locked out forever.
+					atomic.StoreInt32(&f.requestSent, 0)
+					return
+				}
+				log.Trace("Fetcher is doing a request", "addr", f.addr, "fetcher", f.id, "netstore", f.netstoreId, "hopCount", hopCount)
+				netstoreFetcherRequestCount.Inc(1)
+				netstoreFetcherHopCountHist.Update(int64(hopCount))
+				f.netFetcher.Request(rctx, hopCount)
+			}()
+		} else {
+			netstoreFetcherCoalescedCount.Inc(1)
+		}
 	}
 
-	// wait until either the chunk is delivered or the context is done
-	log.Warn("Fetcher is waiting for put", "addr", f.addr, "fetcher", f.id, "netstore", f.netstoreId)
-	select {
-	case <-rctx.Done():
-		log.Warn("Fetcher timeout", "addr", f.addr, "fetcher", f.id, "netstore", f.netstoreId)
-		return nil, fmt.Errorf("context deadline exceeded, addr %v, netstore %v", f.addr, f.netstoreId)
-	case <-f.deliveredC:
-		log.Warn("Fetcher is done", "addr", f.addr, "fetcher", f.id, "netstore", f.netstoreId)
-		return f.chunk, nil
-	}
+	go func() {
+		// wait until either the chunk is delivered or the context is done
+		log.Trace("Fetcher is waiting for put", "addr", f.addr, "fetcher", f.id, "netstore", f.netstoreId)
+		var result ChunkResult
+		select {
+		case <-rctx.Done():
+			log.Trace("Fetcher timeout", "addr", f.addr, "fetcher", f.id, "netstore", f.netstoreId)
+			result = ChunkResult{Err: fmt.Errorf("context deadline exceeded, addr %v, netstore %v", f.addr, f.netstoreId)}
+		case <-f.deliveredC:
+			log.Trace("Fetcher is done", "addr", f.addr, "fetcher", f.id, "netstore", f.netstoreId)
+			netstoreFetcherLatencyTimer.UpdateSince(start)
+			result = ChunkResult{Chunk: f.chunk}
+		}
+
+		// settle this request before handing out the result, so a caller that has received it
+		// already sees the peer removed and, for the last request, the fetcher destroyed
+		if origin.Peer != nil {
+			f.peers.Delete(*origin.Peer)
+		}
+		// if all the requests are done the fetcher can be cancelled
+		if atomic.AddInt32(&f.requestCnt, -1) == 0 {
+			f.cancel()
+		}
+		resultC <- result
+		close(resultC)
+	}()
+
+	return resultC
 }
 
 // deliver is called by NetStore.Put to notify all pending requests
@@ -0,0 +1,374 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/discover"
+)
+
+// testNetFetcher is a NetFetcher that records the hop count of every Request it receives and the
+// number of Offer calls, instead of actually going out to the network.
+type testNetFetcher struct {
+	mu         sync.Mutex
+	requests   []uint8
+	offers     int
+	netstoreId int
+}
+
+func (t *testNetFetcher) Request(ctx context.Context, hopCount uint8) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.requests = append(t.requests, hopCount)
+}
+
+func (t *testNetFetcher) Offer(ctx context.Context, source *discover.NodeID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.offers++
+}
+
+func (t *testNetFetcher) SetNetstoreId(id int) {
+	t.netstoreId = id
+}
+
+func (t *testNetFetcher) requestCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.requests)
+}
+
+func (t *testNetFetcher) minRequestHopCount() uint8 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	min := uint8(255)
+	for _, h := range t.requests {
+		if h < min {
+			min = h
+		}
+	}
+	return min
+}
+
+func (t *testNetFetcher) offerCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.offers
+}
+
+// newTestNetStore creates a NetStore backed by an in-memory ChunkStore and a single shared
+// testNetFetcher, so tests can assert on how many/which requests a fetcher actually issued.
+func newTestNetStore(t *testing.T) (*NetStore, *testNetFetcher) {
+	t.Helper()
+	nf := &testNetFetcher{}
+	n, err := NewNetStore(NewMapChunkStore(), func(ctx context.Context, addr Address, peers *sync.Map) NetFetcher {
+		return nf
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n, nf
+}
+
+// TestFetchAsyncCoalescesConcurrentRequests checks that many concurrent FetchAsync calls for the
+// same, never-delivered chunk are coalesced into a single outgoing NetFetcher.Request, carrying
+// the lowest hop count among all of them.
+func TestFetchAsyncCoalescesConcurrentRequests(t *testing.T) {
+	n, nf := newTestNetStore(t)
+	ref := GenerateRandomChunk(100).Address()
+
+	const concurrency = 8
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(hopCount uint8) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			ctx = WithRequestOrigin(ctx, RequestOrigin{HopCount: hopCount})
+			<-n.GetAsync(ctx, ref)
+		}(uint8(i))
+	}
+	wg.Wait()
+
+	if got := nf.requestCount(); got != 1 {
+		t.Fatalf("expected exactly one coalesced outgoing request, got %d", got)
+	}
+	if got, want := nf.minRequestHopCount(), uint8(1); got != want {
+		t.Fatalf("expected the coalesced request to carry the lowest hop count %d, got %d", want, got)
+	}
+}
+
+// TestFetchAsyncRetriesAfterHopCountRejection checks that a fetcher which declines to forward a
+// request because its hop count exceeds MaxHopCount does not get permanently stuck: a later
+// Fetch with an acceptable hop count must still be able to trigger an outgoing request.
+func TestFetchAsyncRetriesAfterHopCountRejection(t *testing.T) {
+	n, nf := newTestNetStore(t)
+	n.MaxHopCount = 2
+	ref := GenerateRandomChunk(100).Address()
+
+	// Keep the first call's context alive so its fetcher is not torn down (requestCnt never
+	// reaches zero) before the second call arrives; otherwise the second call would simply get a
+	// brand new fetcher, and the permanent-lockout bug this test targets could never show up.
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	defer cancel1()
+	resultC1 := n.GetAsync(WithRequestOrigin(ctx1, RequestOrigin{HopCount: 10}), ref)
+
+	// Give the first call's goroutine time to claim the single request slot, wait out the
+	// coalescing window, find its hop count over budget, and release the claim again.
+	time.Sleep(50 * time.Millisecond)
+	if got := nf.requestCount(); got != 0 {
+		t.Fatalf("expected no request to be sent for a hop count over budget, got %d", got)
+	}
+
+	// A later call with an acceptable hop count, on the same still-live fetcher, must still be
+	// able to claim the slot and send a request.
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	resultC2 := n.GetAsync(WithRequestOrigin(ctx2, RequestOrigin{HopCount: 0}), ref)
+
+	time.Sleep(50 * time.Millisecond)
+	if got := nf.requestCount(); got != 1 {
+		t.Fatalf("expected the request with an acceptable hop count to be sent, got %d requests", got)
+	}
+
+	cancel1()
+	cancel2()
+	<-resultC1
+	<-resultC2
+}
+
+// TestGetAsyncDeliversOnPut checks that a GetAsync call for a chunk that is not yet available
+// locally completes, with no error, once the chunk is Put into the NetStore.
+func TestGetAsyncDeliversOnPut(t *testing.T) {
+	n, _ := newTestNetStore(t)
+	chunk := GenerateRandomChunk(100)
+
+	resultC := n.GetAsync(context.Background(), chunk.Address())
+	if err := n.Put(context.Background(), chunk); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case result := <-resultC:
+		if result.Err != nil {
+			t.Fatalf("unexpected error: %v", result.Err)
+		}
+		if !bytes.Equal(result.Chunk.Address(), chunk.Address()) {
+			t.Fatalf("got chunk %x, want %x", result.Chunk.Address(), chunk.Address())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for GetAsync to deliver the chunk")
+	}
+}
+
+// TestGetAsyncCancel checks that a GetAsync call for a chunk that is never delivered completes
+// with an error as soon as the passed-in context is done, without blocking the caller.
+func TestGetAsyncCancel(t *testing.T) {
+	n, _ := newTestNetStore(t)
+	ref := GenerateRandomChunk(100).Address()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	resultC := n.GetAsync(ctx, ref)
+	cancel()
+
+	select {
+	case result := <-resultC:
+		if result.Err == nil {
+			t.Fatal("expected an error after the context was cancelled, got nil")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for GetAsync to react to context cancellation")
+	}
+}
+
+// TestHasAsyncCancel checks that HasAsync's returned channel receives a non-nil error as soon as
+// the passed-in context is done, for a chunk that is never delivered.
+func TestHasAsyncCancel(t *testing.T) {
+	n, _ := newTestNetStore(t)
+	ref := GenerateRandomChunk(100).Address()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errC := n.HasAsync(ctx, ref)
+	cancel()
+
+	select {
+	case err := <-errC:
+		if err == nil {
+			t.Fatal("expected an error after the context was cancelled, got nil")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for HasAsync to react to context cancellation")
+	}
+}
+
+// TestPutRacesFetcherDestruction checks that a Put delivering a chunk concurrently with many
+// Fetch calls timing out (each of which may drive the shared fetcher's refcount to zero and
+// trigger its destruction) neither panics nor leaves a stale entry behind in the fetchers map.
+func TestPutRacesFetcherDestruction(t *testing.T) {
+	n, _ := newTestNetStore(t)
+	defer n.Close()
+	chunk := GenerateRandomChunk(100)
+
+	const concurrency = 50
+	resultCs := make([]<-chan ChunkResult, concurrency)
+	for i := 0; i < concurrency; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		resultCs[i] = n.GetAsync(ctx, chunk.Address())
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- n.Put(context.Background(), chunk)
+	}()
+
+	for _, rc := range resultCs {
+		<-rc
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if got := n.RequestsCacheLen(); got != 0 {
+		t.Fatalf("expected no fetchers left once all requests settled, got %d", got)
+	}
+}
+
+// TestReapIdleFetchersRemovesStaleFetcher checks that a fetcher which no request ever drives
+// through the normal destroy path (simulated here by simply never issuing a Fetch on it, so its
+// refcount stays at zero) is still force-removed by the idle-fetcher reaper once it has been idle
+// for longer than IdleFetcherTTL.
+func TestReapIdleFetchersRemovesStaleFetcher(t *testing.T) {
+	n, _ := newTestNetStore(t)
+	defer n.Close()
+	ref := GenerateRandomChunk(100).Address()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f := n.getOrCreateFetcher(ctx, ref)
+	if n.getFetcher(ref) != f {
+		t.Fatal("fetcher was not stored in the fetchers map")
+	}
+
+	n.IdleFetcherTTL = 20 * time.Millisecond
+	go n.reapIdleFetchers()
+
+	deadline := time.Now().Add(time.Second)
+	for n.getFetcher(ref) != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for reapIdleFetchers to remove the stale fetcher")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestRegisterFetcherStrategy checks that a request carrying WithFetcherStrategy is retrieved
+// through the NetFetcher registered under that name instead of the default one.
+func TestRegisterFetcherStrategy(t *testing.T) {
+	n, defaultNf := newTestNetStore(t)
+	altNf := &testNetFetcher{}
+	n.RegisterFetcherStrategy("alt", func(ctx context.Context, addr Address, peers *sync.Map) NetFetcher {
+		return altNf
+	})
+	ref := GenerateRandomChunk(100).Address()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	<-n.GetAsync(WithFetcherStrategy(ctx, "alt"), ref)
+
+	if got := altNf.requestCount(); got != 1 {
+		t.Fatalf("expected the chunk to be retrieved through the \"alt\" strategy, got %d requests on it", got)
+	}
+	if got := defaultNf.requestCount(); got != 0 {
+		t.Fatalf("expected the default strategy not to be used, got %d requests on it", got)
+	}
+}
+
+// TestFetcherStrategyFallsBackToDefault checks that requesting an unregistered strategy name
+// falls back to the default NetFetcher instead of failing the request.
+func TestFetcherStrategyFallsBackToDefault(t *testing.T) {
+	n, defaultNf := newTestNetStore(t)
+	ref := GenerateRandomChunk(100).Address()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	<-n.GetAsync(WithFetcherStrategy(ctx, "does-not-exist"), ref)
+
+	if got := defaultNf.requestCount(); got != 1 {
+		t.Fatalf("expected the request to fall back to the default strategy, got %d requests on it", got)
+	}
+}
+
+// TestFetchAsyncOffer checks that a request whose RequestOrigin carries a Source is treated as an
+// offer: it is passed to NetFetcher.Offer instead of ever triggering an outgoing Request.
+func TestFetchAsyncOffer(t *testing.T) {
+	n, nf := newTestNetStore(t)
+	ref := GenerateRandomChunk(100).Address()
+	source := discover.NodeID{1}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	<-n.GetAsync(WithRequestOrigin(ctx, RequestOrigin{Source: &source}), ref)
+
+	if got := nf.offerCount(); got != 1 {
+		t.Fatalf("expected exactly one Offer call, got %d", got)
+	}
+	if got := nf.requestCount(); got != 0 {
+		t.Fatalf("expected an offer to never trigger an outgoing Request, got %d", got)
+	}
+}
+
+// TestFetchAsyncPeerDedup checks that the requesting peer named in RequestOrigin.Peer is stored
+// in the fetcher's peers map for the lifetime of its request, and removed again once the request
+// settles (is delivered or its context is done), so the peer isn't asked to deliver to itself.
+func TestFetchAsyncPeerDedup(t *testing.T) {
+	n, _ := newTestNetStore(t)
+	ref := GenerateRandomChunk(100).Address()
+	peer := discover.NodeID{2}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	resultC := n.GetAsync(WithRequestOrigin(ctx, RequestOrigin{Peer: &peer}), ref)
+
+	f := n.getFetcher(ref)
+	if f == nil {
+		t.Fatal("expected a fetcher to have been created")
+	}
+	if _, ok := f.peers.Load(peer); !ok {
+		t.Fatal("expected the requesting peer to be stored in the fetcher's peers map while its request is pending")
+	}
+
+	cancel()
+	<-resultC
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, ok := f.peers.Load(peer); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the peer to be removed from the fetcher's peers map once its request settled")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}